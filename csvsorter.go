@@ -1,227 +1,345 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/csv"
+	"errors"
 	"flag"
-	"log"
-	"path/filepath"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
-	"sync"
-	"syscall"
-)
+	"time"
 
-type Record []string						// type alias for csv record
-type Visitor func(*Node)					// type alias for visitor function for tree traversal
-type Comparator func(Record, Record) bool	// type alias for function used to compare two records
+	"github.com/waterfountain1996/csvsorter/pipeline"
+	"github.com/waterfountain1996/csvsorter/progress"
+	"github.com/waterfountain1996/csvsorter/resume"
+	"github.com/waterfountain1996/csvsorter/sort"
+	"github.com/waterfountain1996/csvsorter/storage"
+)
 
-type Tree struct {
-	sortIndex uint
-	root *Node
-} 
+// progressInterval is how often progress reporters are refreshed.
+const progressInterval = 100 * time.Millisecond
 
-type Node struct {
-	val Record
-	left *Node
-	right *Node
-}
+// errStopWalk unwinds peekHeader's storage.Walk as soon as it has seen one
+// file, the same way filepath.SkipAll stops an early WalkDir.
+var errStopWalk = errors.New("stop walk")
 
-func NewTree(sortIndex uint) *Tree {
-	return &Tree{sortIndex: sortIndex, root: nil}
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
-func (t *Tree) Insert(value Record) {
-	if t.root == nil {
-		t.root = &Node{val: value}
-	} else {
-		if t.sortIndex >= uint(len(value)) {
-			log.Fatalf("index out of range\n")
-		}
-		if len(value) != len(t.root.val) {
-			log.Fatalf("invalid record length: %d\n", len(value))
+func run() error {
+	var writer *csv.Writer // result writer
+
+	var ifname, ofname, dirname string // input file, output file, input directory
+	var index uint                     // csv record index to sort by
+	var reverse, skipHeader bool       // reverse output, first row of each file is a header
+	var memBudget int64                // bytes of record data buffered before spilling a run to disk
+	var workers int                    // number of concurrent parser workers
+	var progressMode string            // progress reporter: "tty", "json" or "none"
+	var resumeFile string              // state file written by a previous, interrupted run
+	var spec sort.SortSpec             // sort keys, built up by repeated -k flags
+	var delimiter, quote, comment string
+	var lazyQuotes bool
+	var fieldsPerRecord, encodingName string
+
+	flag.StringVar(&ifname, "i", "", "Input CSV file: a path, \"-\" for stdin, or a stdio://, file://, s3:// or webdav:// URI")
+	flag.StringVar(&ofname, "o", "", "Output CSV file: a path, \"-\" for stdout, or a stdio://, file://, s3:// or webdav:// URI")
+	flag.StringVar(&dirname, "d", "", "Input directory, or an s3:// or webdav:// prefix")
+	flag.UintVar(&index, "f", 1, "Sort records by Nth field")
+	flag.BoolVar(&reverse, "r", false, "Sort records in reverse order")
+	flag.BoolVar(&skipHeader, "h", false, "First row of each input file is a header; -k may reference columns by name")
+	flag.Int64Var(&memBudget, "m", 64<<20, "Bytes of record data to buffer before spilling a sorted run to disk")
+	flag.IntVar(&workers, "j", runtime.NumCPU(), "Number of concurrent parser workers")
+	flag.StringVar(&progressMode, "progress", "tty", "Progress reporter: \"tty\", \"json\" or \"none\"")
+	flag.StringVar(&resumeFile, "resume", "", "Resume a sort from a state.json written by a previous, interrupted run")
+	flag.StringVar(&delimiter, "delimiter", ",", "Field delimiter")
+	flag.StringVar(&quote, "quote", "\"", "Quote character (only \\\" is supported)")
+	flag.StringVar(&comment, "comment", "", "Lines starting with this character are skipped")
+	flag.BoolVar(&lazyQuotes, "lazy-quotes", false, "Relax the rules around quoted fields")
+	flag.StringVar(&fieldsPerRecord, "fields-per-record", "auto", "\"strict\" (every record matches the first) or \"auto\"")
+	flag.StringVar(&encodingName, "encoding", "utf-8", "Input character encoding: \"utf-8\", \"utf-16\" or \"latin1\"")
+	flag.Func("k", "Sort key, repeatable: COLUMN[:KIND[:EXTRA]][:asc|:desc], e.g. \"3:int\" or \"1:date:2006-01-02:desc\"", func(s string) error {
+		kc, err := sort.ParseKeyColumn(s)
+		if err != nil {
+			return err
 		}
-		t.root.insert(value, func(left Record, right Record) bool {
-			return left[t.sortIndex] < right[t.sortIndex]
-		})
+		spec = append(spec, kc)
+		return nil
+	})
+
+	flag.Parse()
+
+	if len(spec) == 0 {
+		spec = sort.SortSpec{{Index: int(index) - 1, Reverse: reverse}}
 	}
-}
 
-func (t *Tree) Traverse(reverse bool, visit Visitor) {
-	if t.root != nil {
-		traverseInOrder(t.root, reverse, visit)
+	if dirname != "" && ifname != "" {
+		return fmt.Errorf("can't use -d and -i flags at once")
 	}
-}
 
-func (n *Node) insert(value Record, cmp Comparator) {
-	if cmp(value, n.val) {
-		if n.left == nil {
-			n.left = &Node{val: value}
-		} else {
-			n.left.insert(value, cmp)
+	var state resume.State
+	if resumeFile != "" {
+		var err error
+		state, err = resume.Load(resumeFile)
+		if err != nil {
+			return fmt.Errorf("loading resume state: %w", err)
 		}
-	} else {
-		if n.right == nil {
-			n.right = &Node{val: value}
-		} else {
-			n.right.insert(value, cmp)
+		if got, want := resumeArgs(os.Args[1:]), state.Args; !slices.Equal(got, want) {
+			return fmt.Errorf("-resume %s: flags %q don't match the interrupted run's flags %q; rerun with the same flags", resumeFile, got, want)
 		}
 	}
-}
 
-func traverseInOrder(node *Node, reverse bool, visit Visitor) {
-	if node == nil {
-		return
+	outArg := ofname
+	if outArg == "" {
+		outArg = "-"
 	}
-
-	var first, second *Node
-	if reverse {
-		first, second = node.right, node.left
-	} else {
-		first, second = node.left, node.right
+	outStorage, outPath, err := storage.Parse(outArg)
+	if err != nil {
+		return fmt.Errorf("-o: %w", err)
 	}
-
-	traverseInOrder(first, reverse, visit)
-	visit(node)
-	traverseInOrder(second, reverse, visit)
-}
-
-func BuildTreeFromStream(tree *Tree, stream <-chan Record) {
-	for record := range stream {
-		tree.Insert(record)
+	out, err := outStorage.Create(context.Background(), outPath)
+	if err != nil {
+		return err
 	}
-}
+	defer out.Close()
+	writer = csv.NewWriter(out)
 
-func WriteTree(tree *Tree, writer *csv.Writer, reverse bool) {
-	tree.Traverse(reverse, func(node *Node) {
-		writer.Write(node.val)
-	})
-	writer.Flush()
-}
+	statePath := "state.json"
+	if ofname != "" && !strings.Contains(ofname, "://") {
+		statePath = filepath.Join(filepath.Dir(ofname), "state.json")
+	}
 
-func ReadCSVFromFile(filename string, stream chan<- Record, wg *sync.WaitGroup) {
-	f, err := os.Open(filename)
+	inArg := ifname
+	if dirname != "" {
+		inArg = dirname
+	}
+	if inArg == "" {
+		inArg = "-"
+	}
+	inStorage, inPath, err := storage.Parse(inArg)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("-i/-d: %w", err)
 	}
-	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			break
+	src := pipeline.Source{Storage: inStorage}
+	if dirname != "" {
+		src.Dir = inPath
+	} else {
+		src.File = inPath
+	}
+	if resumeFile != "" {
+		src.Skip = make(map[string]bool, len(state.ConsumedFiles))
+		for _, f := range state.ConsumedFiles {
+			src.Skip[f] = true
 		}
-
-		value := strings.Split(line, ",")
-		stream <- value
 	}
 
-	wg.Done()
-}
+	delimRune, err := parseDialectRune(delimiter)
+	if err != nil {
+		return fmt.Errorf("-delimiter: %w", err)
+	}
+	quoteRune, err := parseDialectRune(quote)
+	if err != nil {
+		return fmt.Errorf("-quote: %w", err)
+	}
+	commentRune, err := parseDialectRune(comment)
+	if err != nil {
+		return fmt.Errorf("-comment: %w", err)
+	}
+	dialect := pipeline.Dialect{
+		Delimiter:       delimRune,
+		Quote:           quoteRune,
+		Comment:         commentRune,
+		LazyQuotes:      lazyQuotes,
+		FieldsPerRecord: fieldsPerRecord,
+		Encoding:        encodingName,
+	}
 
-func ReadIncomingFiles(fileStream <-chan string, recordStream chan<- Record, wg *sync.WaitGroup) {
-	for filename := range fileStream {
-		wg.Add(1)
-		go ReadCSVFromFile(filename, recordStream, wg)
+	var header []string
+	if skipHeader {
+		header, err = peekHeader(src, dialect)
+		if err != nil {
+			return fmt.Errorf("reading header: %w", err)
+		}
+		if needsHeader(spec) {
+			if err := spec.Resolve(header); err != nil {
+				return err
+			}
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
 	}
-	wg.Done()
-}
 
-func main() {
-	var tree *Tree						// binary sort tree
-	var writer *csv.Writer				// result writer
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var reporter progress.Reporter
+	switch progressMode {
+	case "tty":
+		reporter = progress.NewTTYReporter(os.Stderr)
+	case "json":
+		reporter = progress.NewJSONReporter(os.Stderr)
+	case "none":
+	default:
+		return fmt.Errorf("unknown -progress mode %q", progressMode)
+	}
 
-	var ifname, ofname, dirname string	// input file, output file, input directory
-	var index uint						// csv record index to sort by
-	var reverse, skipHeader bool		// reverse output, omit header sorting
+	var prog *progress.Progress
+	if reporter != nil {
+		prog = progress.New(reporter, progressInterval)
+		defer prog.Stop()
+	}
 
-	var wg sync.WaitGroup				// concurrent reader synchronization
-	var fileStream chan string			// csv file stream
-	var recordStream chan Record		// csv record stream
-	var backgroundTask func()			// goroutine for listing directory contents
+	cp := pipeline.NewCheckpointer()
+	p := pipeline.Run(ctx, src, pipeline.Config{
+		Workers:      workers,
+		Storage:      inStorage,
+		Dialect:      dialect,
+		Progress:     prog,
+		Checkpointer: cp,
+		Offsets:      state.InProgress,
+		SkipHeader:   skipHeader,
+		Header:       header,
+	})
 
-	flag.StringVar(&ifname, "i", "", "Input CSV file")
-	flag.StringVar(&ofname, "o", "", "Output CSV file")
-	flag.StringVar(&dirname, "d", "", "Input directory")
-	flag.UintVar(&index, "f", 1, "Sort records by Nth field")
-	flag.BoolVar(&reverse, "r", false, "Sort records in reverse order")
-	flag.BoolVar(&skipHeader, "h", false, "Ignore header when sorting")
+	// Sort is handed the pipeline's own errgroup-derived context, not ctx,
+	// so a failing ParserWorker cancels Sort the same way it cancels the
+	// rest of the pipeline; otherwise Sort would see a plain channel close,
+	// take its in-memory fast path, and write a truncated result as if it
+	// were complete.
+	sortErr := sort.Sort(p.Context(), p.Records(), writer, sort.Options{
+		Spec:         spec,
+		MemoryBudget: memBudget,
+		Progress:     prog,
+		ExistingRuns: state.Runs,
+	})
 
-	flag.Parse()
-	
-	tree = NewTree(index - 1)
+	pipeErr := p.Wait()
+
+	var cancelled *sort.CancelledError
+	if errors.As(sortErr, &cancelled) {
+		if ctx.Err() != nil {
+			consumed, inProgress := cp.Snapshot()
+			newState := resume.State{
+				Args:          resumeArgs(os.Args[1:]),
+				ConsumedFiles: append(state.ConsumedFiles, consumed...),
+				InProgress:    inProgress,
+				Runs:          cancelled.Runs,
+			}
+			if err := resume.Save(statePath, newState); err != nil {
+				return fmt.Errorf("saving resume state: %w", err)
+			}
+			return fmt.Errorf("sort interrupted: state saved to %s, resume with -resume %s", statePath, statePath)
+		}
 
-	if ofname != "" {
-		f, err := os.Create(ofname)
-		if err != nil {
-			log.Fatal(err)
+		// Sort only aborts mid-flight like this when something else
+		// cancelled its context; since ctx itself wasn't interrupted, the
+		// cause is a failing pipeline stage, not a real interrupt. The
+		// runs it spilled reflect an incomplete read, so there's nothing
+		// worth resuming from — discard them and report pipeErr below.
+		for _, run := range cancelled.Runs {
+			os.Remove(run)
 		}
-		defer f.Close()
-		writer = csv.NewWriter(f)
-	} else {
-		writer = csv.NewWriter(os.Stdout)
 	}
 
-	if dirname != "" && ifname != "" {
-		log.Fatal("can't use -d and -i flags at once")
+	if pipeErr != nil && ctx.Err() == nil {
+		return pipeErr
+	}
+	if sortErr != nil {
+		return sortErr
 	}
 
-	fileStream = make(chan string)
-	recordStream = make(chan Record)
-
-	// launch tree builder goroutine
-	wg.Add(1)
-	go BuildTreeFromStream(tree, recordStream)
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT)
-	go func() {
-		<- sigChan
-		WriteTree(tree, writer, reverse)
-		os.Exit(0)
-	}()
-
-	// launch file reader goroutine
-	wg.Add(1)
-	go ReadIncomingFiles(fileStream, recordStream, &wg)
+	if resumeFile != "" {
+		os.Remove(resumeFile)
+	}
+	return nil
+}
 
-	if dirname != "" {
-		// walk through directory and send each .csv file to stream
-		backgroundTask = func() {
-			err := filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if strings.HasSuffix(info.Name(), ".csv") {
-					fileStream <- path
-				}
-				return nil
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-			wg.Done()
+// needsHeader reports whether spec has any key column referenced by name,
+// which requires a header row to resolve into an index.
+func needsHeader(spec sort.SortSpec) bool {
+	for _, kc := range spec {
+		if kc.Name != "" {
+			return true
 		}
-	} else {
-		// use stdin if no input file is provided
-		if ifname == "" {
-			ifname = os.Stdin.Name()
+	}
+	return false
+}
+
+// peekHeader reads the header row of src's first input file, using dialect
+// to parse it, so it can be validated against every other file's header and
+// named -k columns can be resolved to an index before the pipeline starts.
+func peekHeader(src pipeline.Source, dialect pipeline.Dialect) ([]string, error) {
+	name := src.File
+	if src.Dir != "" {
+		name = ""
+		err := src.Storage.Walk(context.Background(), src.Dir, func(path string) error {
+			name = path
+			return errStopWalk
+		})
+		if err != nil && !errors.Is(err, errStopWalk) {
+			return nil, err
 		}
-		// send a single file to stream
-		backgroundTask = func() {
-			fileStream <- ifname
-			wg.Done()
+		if name == "" {
+			return nil, fmt.Errorf("no .csv files found in %s", src.Dir)
 		}
+	} else if name == "-" {
+		return nil, fmt.Errorf("cannot use -h when reading from stdin: the header can't be peeked without consuming it")
 	}
 
-	wg.Add(1)
-	go backgroundTask()
+	f, err := src.Storage.Open(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	wg.Done()	// ReadIncomingFiles
-	wg.Done()	// BuildTreeFromStream
-	wg.Wait()
+	r, err := dialect.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return r.Read()
+}
 
-	WriteTree(tree, writer, reverse)
+// resumeArgs strips the -resume flag and its value out of args, so the
+// flags an interrupted run was invoked with can be compared against a
+// later -resume invocation's flags without the latter's -resume always
+// making them look different.
+func resumeArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-resume" || args[i] == "--resume":
+			i++
+		case strings.HasPrefix(args[i], "-resume=") || strings.HasPrefix(args[i], "--resume="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
+
+// parseDialectRune parses a single-character dialect flag value (-delimiter,
+// -quote, -comment) into a rune. An empty string means "unset" and maps to
+// rune 0, and the literal "\t" is accepted as a convenience for a tab.
+func parseDialectRune(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case `\t`:
+		return '\t', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("must be exactly one character, got %q", s)
+	}
+	return runes[0], nil
 }
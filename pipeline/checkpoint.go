@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"sync"
+)
+
+// Checkpointer tracks how many records the pipeline has consumed from each
+// input file, so a sort interrupted midway can resume instead of redoing
+// completed work. ParserWorkers update it as they read records; Snapshot
+// is called once, on interrupt, to build a resume.State.
+//
+// Progress is tracked by record count rather than byte offset: a file is
+// resumed by re-decoding it from the start and skipping that many records,
+// not by seeking. A byte offset recorded against the decoded stream (what
+// encoding/csv.Reader.InputOffset reports) doesn't correspond to the same
+// position in the raw file once Dialect decodes anything other than plain
+// UTF-8 or strips a leading BOM, so seeking to it can silently skip or
+// re-read the wrong records.
+type Checkpointer struct {
+	mu      sync.Mutex
+	records map[string]int64
+	done    map[string]bool
+}
+
+// NewCheckpointer returns an empty Checkpointer.
+func NewCheckpointer() *Checkpointer {
+	return &Checkpointer{
+		records: make(map[string]int64),
+		done:    make(map[string]bool),
+	}
+}
+
+func (c *Checkpointer) update(file string, n int64) {
+	c.mu.Lock()
+	c.records[file] = n
+	c.mu.Unlock()
+}
+
+func (c *Checkpointer) markDone(file string) {
+	c.mu.Lock()
+	c.done[file] = true
+	delete(c.records, file)
+	c.mu.Unlock()
+}
+
+// Snapshot returns the files that were read to completion, and the number
+// of records consumed from any file still open when it was taken.
+func (c *Checkpointer) Snapshot() (consumed []string, inProgress map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for file := range c.done {
+		consumed = append(consumed, file)
+	}
+	inProgress = make(map[string]int64, len(c.records))
+	for file, n := range c.records {
+		inProgress[file] = n
+	}
+	return consumed, inProgress
+}
@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCheckpointerSnapshot(t *testing.T) {
+	c := NewCheckpointer()
+	c.update("a.csv", 1)
+	c.update("a.csv", 2)
+	c.update("b.csv", 5)
+	c.markDone("c.csv")
+
+	consumed, inProgress := c.Snapshot()
+	sort.Strings(consumed)
+
+	if want := []string{"c.csv"}; !reflect.DeepEqual(consumed, want) {
+		t.Errorf("consumed = %v, want %v", consumed, want)
+	}
+	want := map[string]int64{"a.csv": 2, "b.csv": 5}
+	if !reflect.DeepEqual(inProgress, want) {
+		t.Errorf("inProgress = %v, want %v", inProgress, want)
+	}
+}
+
+func TestCheckpointerMarkDoneClearsInProgress(t *testing.T) {
+	c := NewCheckpointer()
+	c.update("a.csv", 3)
+	c.markDone("a.csv")
+
+	consumed, inProgress := c.Snapshot()
+	if want := []string{"a.csv"}; !reflect.DeepEqual(consumed, want) {
+		t.Errorf("consumed = %v, want %v", consumed, want)
+	}
+	if len(inProgress) != 0 {
+		t.Errorf("inProgress = %v, want empty", inProgress)
+	}
+}
@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Dialect configures how CSV input is parsed: delimiter, quoting and
+// character encoding.
+type Dialect struct {
+	// Delimiter separates fields. Defaults to ','.
+	Delimiter rune
+	// Quote is the quote character. encoding/csv only supports '"', so
+	// anything else is rejected by NewReader.
+	Quote rune
+	// Comment, if set, marks a line as a comment to be skipped entirely.
+	Comment rune
+	// LazyQuotes relaxes the quoting rules, as encoding/csv.LazyQuotes.
+	LazyQuotes bool
+	// FieldsPerRecord is "strict" (every record must match the first
+	// record's field count) or "auto" (any field count is allowed).
+	FieldsPerRecord string
+	// Encoding is the input's character encoding: "utf-8", "utf-16" or
+	// "latin1". Defaults to "utf-8". A leading byte-order mark is
+	// detected and stripped regardless of the declared encoding.
+	Encoding string
+}
+
+// DefaultDialect is plain RFC 4180: comma-delimited, double-quoted, UTF-8.
+var DefaultDialect = Dialect{Delimiter: ',', Quote: '"', FieldsPerRecord: "auto", Encoding: "utf-8"}
+
+// NewReader builds a csv.Reader over r configured according to d. r is
+// transcoded to UTF-8 first, and a leading BOM is stripped if present.
+func (d Dialect) NewReader(r io.Reader) (*csv.Reader, error) {
+	if d.Quote != 0 && d.Quote != '"' {
+		return nil, fmt.Errorf("unsupported quote character %q: encoding/csv only supports '\"'", d.Quote)
+	}
+
+	dec, err := d.decoder()
+	if err != nil {
+		return nil, err
+	}
+	r = transform.NewReader(r, dec)
+
+	cr := csv.NewReader(r)
+	cr.Comma = ','
+	if d.Delimiter != 0 {
+		cr.Comma = d.Delimiter
+	}
+	cr.Comment = d.Comment
+	cr.LazyQuotes = d.LazyQuotes
+
+	switch d.FieldsPerRecord {
+	case "strict":
+		cr.FieldsPerRecord = 0
+	case "", "auto":
+		cr.FieldsPerRecord = -1
+	default:
+		return nil, fmt.Errorf("unknown fields-per-record mode %q: want \"strict\" or \"auto\"", d.FieldsPerRecord)
+	}
+
+	return cr, nil
+}
+
+// decoder returns the transform that turns d.Encoding, with a leading BOM
+// stripped, into UTF-8.
+func (d Dialect) decoder() (transform.Transformer, error) {
+	switch d.Encoding {
+	case "", "utf-8", "utf8":
+		return unicode.BOMOverride(encoding.Nop.NewDecoder()), nil
+	case "utf-16", "utf16":
+		return unicode.BOMOverride(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()), nil
+	case "latin1", "iso-8859-1":
+		return unicode.BOMOverride(charmap.ISO8859_1.NewDecoder()), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q: want \"utf-8\", \"utf-16\" or \"latin1\"", d.Encoding)
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func readAll(t *testing.T, d Dialect, input string) [][]string {
+	t.Helper()
+
+	r, err := d.NewReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestDialectNewReaderStripsUTF8BOM(t *testing.T) {
+	input := "\xEF\xBB\xBFa,b\n1,2\n"
+	rows := readAll(t, DefaultDialect, input)
+
+	want := [][]string{{"a", "b"}, {"1", "2"}}
+	if len(rows) != len(want) || rows[0][0] != "a" {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+}
+
+func TestDialectNewReaderDecodesUTF16(t *testing.T) {
+	enc := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()
+	encoded, err := enc.String("a,b\n1,2\n")
+	if err != nil {
+		t.Fatalf("encoding fixture: %v", err)
+	}
+
+	d := DefaultDialect
+	d.Encoding = "utf-16"
+	rows := readAll(t, d, encoded)
+
+	if len(rows) != 2 || rows[0][0] != "a" || rows[1][1] != "2" {
+		t.Fatalf("got %v", rows)
+	}
+}
+
+func TestDialectNewReaderCustomDelimiter(t *testing.T) {
+	d := DefaultDialect
+	d.Delimiter = ';'
+	rows := readAll(t, d, "a;b\n1;2\n")
+
+	if len(rows) != 2 || rows[1][0] != "1" || rows[1][1] != "2" {
+		t.Fatalf("got %v", rows)
+	}
+}
+
+func TestDialectNewReaderRejectsUnsupportedQuote(t *testing.T) {
+	d := DefaultDialect
+	d.Quote = '\''
+	if _, err := d.NewReader(strings.NewReader("a,b\n")); err == nil {
+		t.Fatal("expected an error for a non-\" quote character")
+	}
+}
+
+func TestDialectNewReaderRejectsUnknownEncoding(t *testing.T) {
+	d := DefaultDialect
+	d.Encoding = "ebcdic"
+	if _, err := d.NewReader(strings.NewReader("a,b\n")); err == nil {
+		t.Fatal("expected an error for an unknown encoding")
+	}
+}
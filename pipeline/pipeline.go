@@ -0,0 +1,271 @@
+// Package pipeline implements a concurrent read/parse pipeline for CSV
+// inputs, modeled on restic's walker/worker pattern: a Source stage lists
+// the files to read, a pool of ParserWorkers decodes them concurrently,
+// and their output is merged onto a single record channel. Every stage is
+// wired to a context.Context, so cancelling it (e.g. on SIGINT) unwinds
+// the whole pipeline instead of exiting mid-write from a signal handler.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/waterfountain1996/csvsorter/progress"
+	"github.com/waterfountain1996/csvsorter/sort"
+	"github.com/waterfountain1996/csvsorter/storage"
+)
+
+// Source describes where the pipeline should read CSV input from. Storage
+// and Dir/File are usually the result of storage.Parse on a -i/-o/-d flag.
+type Source struct {
+	// Storage is the backend files are listed and opened through.
+	Storage storage.Storage
+	// Dir, when non-empty, is walked recursively for *.csv files.
+	Dir string
+	// File is a single input file or key, used when Dir is empty.
+	File string
+	// Skip lists files that were already fully consumed by a previous,
+	// interrupted run and should not be read again.
+	Skip map[string]bool
+}
+
+// walk sends every input file name on files, closing it once done or as
+// soon as ctx is cancelled. Files in s.Skip are left out entirely.
+func (s Source) walk(ctx context.Context, files chan<- string) error {
+	defer close(files)
+
+	if s.Dir == "" {
+		if s.Skip[s.File] {
+			return nil
+		}
+		select {
+		case files <- s.File:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return s.Storage.Walk(ctx, s.Dir, func(path string) error {
+		if s.Skip[path] {
+			return nil
+		}
+		select {
+		case files <- path:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Config bundles the optional features layered onto a pipeline Run.
+type Config struct {
+	// Workers is the number of concurrent ParserWorkers.
+	Workers int
+
+	// Storage is the backend input files are opened through. It must
+	// match the Storage used to build Source.
+	Storage storage.Storage
+
+	// Dialect configures delimiter, quoting and encoding. The zero value
+	// parses the same way as DefaultDialect.
+	Dialect Dialect
+
+	// Progress, if non-nil, is fed read throughput as files are decoded.
+	Progress *progress.Progress
+
+	// Checkpointer, if non-nil, is updated with the number of records
+	// consumed from each file as it is read, and told once a file is
+	// fully consumed.
+	Checkpointer *Checkpointer
+
+	// Offsets, if set, skips the given number of already-read records at
+	// the start of a file, resuming a file that was only partially read
+	// by a previous, interrupted run. Resuming re-parses the file from
+	// the start rather than seeking into it; see Checkpointer.
+	Offsets map[string]int64
+
+	// SkipHeader discards the first record of every file, since each
+	// input file is assumed to carry its own copy of the same header.
+	SkipHeader bool
+
+	// Header, when SkipHeader is set and non-nil, is the header every
+	// file's first record is validated against; a mismatch aborts the
+	// pipeline instead of silently sorting incompatible columns together.
+	Header []string
+}
+
+// ParserWorker decodes CSV records from the files it receives and sends
+// them on records, using encoding/csv so quoted fields containing commas
+// are handled correctly.
+type ParserWorker struct {
+	Config
+}
+
+func (w ParserWorker) run(ctx context.Context, files <-chan string, records chan<- sort.Record) error {
+	for {
+		select {
+		case name, ok := <-files:
+			if !ok {
+				return nil
+			}
+			if err := w.parseFile(ctx, name, records); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w ParserWorker) parseFile(ctx context.Context, name string, records chan<- sort.Record) error {
+	isStdin := name == "-"
+
+	f, err := w.Storage.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if w.Progress != nil {
+		w.Progress.Add(progress.Stats{FilesRead: 1})
+	}
+
+	var skip int64
+	if !isStdin {
+		skip = w.Offsets[name]
+	}
+
+	r, err := w.Dialect.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	if w.SkipHeader {
+		header, err := r.Read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if w.Header != nil && !reflect.DeepEqual(header, w.Header) {
+			return fmt.Errorf("%s: header %v does not match expected header %v", name, header, w.Header)
+		}
+	}
+
+	// Resuming skips records instead of seeking to a byte offset: see the
+	// Checkpointer doc comment for why a byte offset isn't safe once the
+	// input goes through Dialect's decode transform.
+	var n int64
+	for ; n < skip; n++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if _, err := r.Read(); err != nil {
+			if err == io.EOF {
+				if w.Checkpointer != nil {
+					w.Checkpointer.markDone(name)
+				}
+				return nil
+			}
+			return err
+		}
+	}
+
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			if w.Checkpointer != nil {
+				w.Checkpointer.markDone(name)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n++
+
+		select {
+		case records <- sort.Record(row):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if w.Progress != nil {
+			var size int64
+			for _, field := range row {
+				size += int64(len(field))
+			}
+			w.Progress.Add(progress.Stats{RecordsRead: 1, BytesRead: size})
+		}
+		if w.Checkpointer != nil && !isStdin {
+			w.Checkpointer.update(name, n)
+		}
+	}
+}
+
+// Pipeline is a running Source -> []ParserWorker chain whose output is
+// merged onto a single records channel.
+type Pipeline struct {
+	records chan sort.Record
+	g       *errgroup.Group
+	ctx     context.Context
+}
+
+// Run starts the pipeline: src lists the input files, and cfg.Workers
+// parser goroutines decode them concurrently according to cfg. The
+// returned Pipeline's Records channel is closed once every file has been
+// parsed, or as soon as ctx is cancelled or a worker errors. Call Wait to
+// collect the first error from any stage.
+func Run(ctx context.Context, src Source, cfg Config) *Pipeline {
+	g, ctx := errgroup.WithContext(ctx)
+
+	files := make(chan string)
+	records := make(chan sort.Record)
+
+	g.Go(func() error {
+		return src.walk(ctx, files)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		g.Go(func() error {
+			defer wg.Done()
+			return ParserWorker{cfg}.run(ctx, files, records)
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	return &Pipeline{records: records, g: g, ctx: ctx}
+}
+
+// Records is the stream of decoded CSV records.
+func (p *Pipeline) Records() <-chan sort.Record {
+	return p.records
+}
+
+// Context returns the pipeline's internal, errgroup-derived context. It is
+// cancelled not only when the context passed to Run is, but also as soon as
+// any stage returns an error, so a consumer reading Records (e.g. sort.Sort)
+// can tell a worker failure apart from a clean channel close and abort
+// instead of treating a truncated Records stream as the complete result.
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// Wait blocks until every stage has finished and returns the first error
+// encountered, if any.
+func (p *Pipeline) Wait() error {
+	return p.g.Wait()
+}
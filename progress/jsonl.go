@@ -0,0 +1,25 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONReporter emits one JSON object per line for each report, so the
+// tool can be driven from other programs via --progress=json.
+type JSONReporter struct {
+	enc *json.Encoder
+}
+
+// NewJSONReporter returns a Reporter that writes newline-delimited JSON
+// to w.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONReporter) Report(s Stats) {
+	// Best-effort: a write failure here shouldn't abort the sort.
+	r.enc.Encode(s)
+}
+
+func (r *JSONReporter) Done() {}
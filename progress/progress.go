@@ -0,0 +1,89 @@
+// Package progress tracks aggregate throughput counters for a sort run
+// and periodically hands them to a pluggable Reporter, modeled on
+// restic's Progress type.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the work done so far.
+type Stats struct {
+	FilesRead      int64 `json:"files_read"`
+	RecordsRead    int64 `json:"records_read"`
+	BytesRead      int64 `json:"bytes_read"`
+	RecordsWritten int64 `json:"records_written"`
+}
+
+// Reporter receives periodic Stats snapshots.
+type Reporter interface {
+	// Report is called with the running totals roughly every interval.
+	Report(Stats)
+	// Done is called once after the final Report, so the reporter can
+	// restore any terminal state it changed.
+	Done()
+}
+
+// Progress accumulates Stats deltas and reports the running totals to a
+// Reporter every interval until Stop is called.
+type Progress struct {
+	reporter Reporter
+	ticker   *time.Ticker
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New starts a Progress that reports to reporter every interval.
+func New(reporter Reporter, interval time.Duration) *Progress {
+	p := &Progress{
+		reporter: reporter,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-p.ticker.C:
+				p.report()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// Add adds delta to the running totals.
+func (p *Progress) Add(delta Stats) {
+	p.mu.Lock()
+	p.stats.FilesRead += delta.FilesRead
+	p.stats.RecordsRead += delta.RecordsRead
+	p.stats.BytesRead += delta.BytesRead
+	p.stats.RecordsWritten += delta.RecordsWritten
+	p.mu.Unlock()
+}
+
+func (p *Progress) report() {
+	p.mu.Lock()
+	stats := p.stats
+	p.mu.Unlock()
+	p.reporter.Report(stats)
+}
+
+// Stop stops periodic reporting, emits one final report with the latest
+// totals, and tears down the reporter.
+func (p *Progress) Stop() {
+	p.ticker.Stop()
+	close(p.done)
+	p.wg.Wait()
+	p.report()
+	p.reporter.Done()
+}
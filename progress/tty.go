@@ -0,0 +1,42 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// TTYReporter renders a single, periodically-updating status line. It is
+// a no-op when the underlying writer isn't a terminal, e.g. when stderr
+// has been redirected to a file.
+type TTYReporter struct {
+	w      io.Writer
+	isTerm bool
+}
+
+// NewTTYReporter returns a Reporter that renders progress on w.
+func NewTTYReporter(w io.Writer) *TTYReporter {
+	isTerm := false
+	if f, ok := w.(*os.File); ok {
+		isTerm = term.IsTerminal(int(f.Fd()))
+	}
+	return &TTYReporter{w: w, isTerm: isTerm}
+}
+
+func (r *TTYReporter) Report(s Stats) {
+	if !r.isTerm {
+		return
+	}
+	fmt.Fprintf(r.w, "\r\033[Kfiles %d  records %d  bytes %d  written %d",
+		s.FilesRead, s.RecordsRead, s.BytesRead, s.RecordsWritten)
+}
+
+// Done clears the status line, leaving the cursor at the start of it.
+func (r *TTYReporter) Done() {
+	if !r.isTerm {
+		return
+	}
+	fmt.Fprint(r.w, "\r\033[K")
+}
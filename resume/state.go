@@ -0,0 +1,64 @@
+// Package resume persists and restores the progress of an interrupted
+// sort, so a run cut short by SIGINT can pick up where it left off
+// instead of redoing completed work.
+package resume
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// State is everything needed to resume a sort.
+type State struct {
+	// Args are the CLI flags the interrupted run was invoked with (minus
+	// -resume, which by definition differs between runs), validated
+	// against -resume's own flags so a sort can't be silently resumed
+	// with a different sort key, field index or memory budget than the
+	// run that produced ConsumedFiles, InProgress and Runs.
+	Args []string `json:"args"`
+
+	// ConsumedFiles lists input files that were fully read.
+	ConsumedFiles []string `json:"consumed_files"`
+
+	// InProgress maps an input file that was still being read to the
+	// number of records already consumed from it.
+	InProgress map[string]int64 `json:"in_progress"`
+
+	// Runs lists the external-merge-sort run files spilled so far, so
+	// they can be merged instead of re-sorted.
+	Runs []string `json:"runs"`
+}
+
+// Save atomically writes state to path, so a crash mid-write never leaves
+// a truncated, unreadable state file behind.
+func Save(path string, state State) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(state); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads a State previously written by Save.
+func Load(path string) (State, error) {
+	var state State
+	f, err := os.Open(path)
+	if err != nil {
+		return state, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&state)
+	return state, err
+}
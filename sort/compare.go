@@ -0,0 +1,160 @@
+package sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// comparator builds a Comparator from spec, comparing keys in order and
+// deciding on the first one that differs.
+func (spec SortSpec) comparator() (Comparator, error) {
+	cmps := make([]func(Record, Record) int, len(spec))
+	for i, kc := range spec {
+		cmp, err := kc.valueComparator()
+		if err != nil {
+			return nil, err
+		}
+		cmps[i] = cmp
+	}
+
+	return func(left, right Record) bool {
+		for _, cmp := range cmps {
+			if c := cmp(left, right); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}, nil
+}
+
+// valueComparator returns a function comparing the field kc.Index of two
+// records, honoring Kind, Locale, DateFormat, NullsFirst and Reverse.
+func (kc KeyColumn) valueComparator() (func(left, right Record) int, error) {
+	field := func(r Record) string {
+		if kc.Index < 0 || kc.Index >= len(r) {
+			return ""
+		}
+		return r[kc.Index]
+	}
+
+	var cmp func(a, b string) int
+	switch kc.Kind {
+	case KindInt:
+		cmp = compareInt
+	case KindFloat:
+		cmp = compareFloat
+	case KindDate:
+		format := kc.DateFormat
+		if format == "" {
+			format = time.RFC3339
+		}
+		cmp = func(a, b string) int { return compareDate(a, b, format) }
+	case KindBool:
+		cmp = compareBool
+	default:
+		var err error
+		cmp, err = stringComparator(kc.Locale)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reverse := kc.Reverse
+	return func(left, right Record) int {
+		a, b := field(left), field(right)
+		if kc.NullsFirst && a != b && (a == "" || b == "") {
+			if a == "" {
+				return -1
+			}
+			return 1
+		}
+		c := cmp(a, b)
+		if reverse {
+			c = -c
+		}
+		return c
+	}, nil
+}
+
+func stringComparator(locale string) (func(a, b string) int, error) {
+	if locale == "" {
+		return strings.Compare, nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locale %q: %w", locale, err)
+	}
+	col := collate.New(tag)
+	return func(a, b string) int { return col.CompareString(a, b) }, nil
+}
+
+// compareInt compares a and b as base-10 integers, falling back to a
+// byte-order string comparison if either fails to parse.
+func compareInt(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBool(a, b string) int {
+	x, errA := strconv.ParseBool(a)
+	y, errB := strconv.ParseBool(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x == y:
+		return 0
+	case !x:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareDate(a, b, format string) int {
+	x, errA := time.Parse(format, a)
+	y, errB := time.Parse(format, b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,155 @@
+package sort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type used to compare a key column's values.
+type Kind int
+
+const (
+	// KindString compares values as plain strings (optionally locale-aware).
+	KindString Kind = iota
+	// KindInt compares values as base-10 integers.
+	KindInt
+	// KindFloat compares values as floating-point numbers.
+	KindFloat
+	// KindDate compares values as dates parsed with a DateFormat layout.
+	KindDate
+	// KindBool compares values as booleans, false sorting before true.
+	KindBool
+)
+
+// ParseKind parses the kind name used in a -k flag, e.g. "int" or "date".
+// An empty string means KindString.
+func ParseKind(s string) (Kind, error) {
+	switch s {
+	case "", "string":
+		return KindString, nil
+	case "int":
+		return KindInt, nil
+	case "float":
+		return KindFloat, nil
+	case "date":
+		return KindDate, nil
+	case "bool":
+		return KindBool, nil
+	default:
+		return 0, fmt.Errorf("unknown key kind %q", s)
+	}
+}
+
+// KeyColumn describes one column to compare records by.
+type KeyColumn struct {
+	// Index is the zero-based field to compare. For a key given by Name,
+	// SortSpec.Resolve sets it to that column's position in the header.
+	Index int
+	// Name is the header column name to compare, used in -h mode. It is
+	// only read by Resolve; comparisons always use Index.
+	Name string
+
+	Kind Kind
+	// Reverse sorts this key in descending order, independent of any
+	// other key's direction.
+	Reverse bool
+	// Locale, for KindString, compares values with locale-aware
+	// collation (e.g. "en", "de") instead of byte order.
+	Locale string
+	// DateFormat, for KindDate, is the time.Parse layout values are
+	// parsed with. Defaults to time.RFC3339.
+	DateFormat string
+	// NullsFirst sorts empty values before non-empty ones, regardless
+	// of Reverse.
+	NullsFirst bool
+}
+
+// SortSpec is an ordered list of key columns used to compare two records:
+// the first key that differs between two records decides their order.
+type SortSpec []KeyColumn
+
+// ParseKeyColumn parses the argument to a single -k flag. The syntax is
+// COLUMN[:KIND[:EXTRA]][:asc|:desc], e.g. "3:int", "1:date:2006-01-02:desc"
+// or, in -h mode, "price:float:desc". COLUMN is a 1-based field
+// index, or a header name when -h is set. EXTRA is the date layout
+// for "date" columns, or a locale for "string" columns.
+//
+// EXTRA is taken verbatim, not re-split on ":", so a date layout with a
+// time-of-day component (e.g. "2006-01-02 15:04:05") survives intact:
+// only COLUMN, KIND and a trailing asc/desc are peeled off the front and
+// back of s, and whatever textually remains in the middle is EXTRA.
+func ParseKeyColumn(s string) (KeyColumn, error) {
+	parts := strings.Split(s, ":")
+	if parts[0] == "" {
+		return KeyColumn{}, fmt.Errorf("invalid key spec %q: missing column", s)
+	}
+
+	var kc KeyColumn
+	if n, err := strconv.Atoi(parts[0]); err == nil {
+		kc.Index = n - 1
+	} else {
+		kc.Name = parts[0]
+	}
+	rest := parts[1:]
+
+	if n := len(rest); n > 0 {
+		switch rest[n-1] {
+		case "asc":
+			rest = rest[:n-1]
+		case "desc":
+			kc.Reverse = true
+			rest = rest[:n-1]
+		}
+	}
+
+	if len(rest) > 0 {
+		kind, err := ParseKind(rest[0])
+		if err != nil {
+			return KeyColumn{}, fmt.Errorf("invalid key spec %q: %w", s, err)
+		}
+		kc.Kind = kind
+		rest = rest[1:]
+	}
+
+	if extra := strings.Join(rest, ":"); extra != "" {
+		switch kc.Kind {
+		case KindDate:
+			kc.DateFormat = extra
+		case KindString:
+			kc.Locale = extra
+		default:
+			return KeyColumn{}, fmt.Errorf("invalid key spec %q: unexpected field %q", s, extra)
+		}
+	}
+
+	if kc.Kind == KindDate && kc.DateFormat == "" {
+		kc.DateFormat = time.RFC3339
+	}
+
+	return kc, nil
+}
+
+// Resolve fills in Index for every key column that was given by Name,
+// looking it up in header. It returns an error if a name isn't present.
+func (spec SortSpec) Resolve(header []string) error {
+	var byName map[string]int
+	for i, kc := range spec {
+		if kc.Name == "" {
+			continue
+		}
+		if byName == nil {
+			byName = make(map[string]int, len(header))
+			for j, name := range header {
+				byName[name] = j
+			}
+		}
+		idx, ok := byName[kc.Name]
+		if !ok {
+			return fmt.Errorf("key column %q not found in header", kc.Name)
+		}
+		spec[i].Index = idx
+	}
+	return nil
+}
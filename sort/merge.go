@@ -0,0 +1,81 @@
+package sort
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"io"
+
+	"github.com/waterfountain1996/csvsorter/progress"
+)
+
+// mergeItem is one candidate record in the k-way merge, tagged with the
+// run it came from so the heap can pull the next record once it wins.
+type mergeItem struct {
+	record Record
+	run    int
+}
+
+// mergeHeap is a container/heap.Interface ordered by cmp over the current
+// head record of each run.
+type mergeHeap struct {
+	items []mergeItem
+	cmp   Comparator
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	return h.cmp(h.items[i].record, h.items[j].record)
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
+
+// merge performs a k-way merge of runs, which must each be internally
+// sorted according to cmp, and writes the combined, sorted output to out.
+func merge(runs *runSet, out *csv.Writer, cmp Comparator, prog *progress.Progress) error {
+	h := &mergeHeap{cmp: cmp}
+	heap.Init(h)
+
+	for i, rf := range runs.files {
+		record, err := rf.next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		heap.Push(h, mergeItem{record: record, run: i})
+	}
+
+	for h.Len() > 0 {
+		item := heap.Pop(h).(mergeItem)
+		if err := out.Write(item.record); err != nil {
+			return err
+		}
+		if prog != nil {
+			prog.Add(progress.Stats{RecordsWritten: 1})
+		}
+
+		next, err := runs.files[item.run].next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		heap.Push(h, mergeItem{record: next, run: item.run})
+	}
+
+	out.Flush()
+	return out.Error()
+}
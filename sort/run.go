@@ -0,0 +1,118 @@
+package sort
+
+import (
+	"encoding/csv"
+	"os"
+	"sync"
+)
+
+// runFile is a sorted run spilled to a temp file on disk.
+type runFile struct {
+	f *os.File
+	r *csv.Reader
+}
+
+// spill writes records, which must already be sorted, to a new temp file
+// as CSV and returns a runFile positioned to read them back.
+func spill(records []Record) (*runFile, error) {
+	f, err := os.CreateTemp("", "csvsorter-run-*.csv")
+	if err != nil {
+		return nil, err
+	}
+
+	w := csv.NewWriter(f)
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &runFile{f: f, r: csv.NewReader(f)}, nil
+}
+
+// openRun reopens a run file spilled by a previous, interrupted sort so
+// its records can be merged without re-sorting them.
+func openRun(path string) (*runFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &runFile{f: f, r: csv.NewReader(f)}, nil
+}
+
+// next returns the next record in the run, or io.EOF once it is exhausted.
+func (rf *runFile) next() (Record, error) {
+	record, err := rf.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return Record(record), nil
+}
+
+// close removes the underlying temp file.
+func (rf *runFile) close() {
+	rf.f.Close()
+	os.Remove(rf.f.Name())
+}
+
+// runSet tracks the runs spilled so far so they can all be cleaned up on
+// error, or handed off to a resume state on interrupt.
+type runSet struct {
+	mu    sync.Mutex
+	files []*runFile
+}
+
+func newRunSet() *runSet {
+	return &runSet{}
+}
+
+func (rs *runSet) add(rf *runFile) {
+	rs.mu.Lock()
+	rs.files = append(rs.files, rf)
+	rs.mu.Unlock()
+}
+
+func (rs *runSet) empty() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return len(rs.files) == 0
+}
+
+// paths returns the on-disk path of every run spilled so far, so they can
+// be recorded in a resume state instead of being thrown away.
+func (rs *runSet) paths() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	paths := make([]string, len(rs.files))
+	for i, rf := range rs.files {
+		paths[i] = rf.f.Name()
+	}
+	return paths
+}
+
+// cleanup removes every spilled run file. It is safe to call more than
+// once; subsequent calls are no-ops.
+func (rs *runSet) cleanup() {
+	rs.mu.Lock()
+	files := rs.files
+	rs.files = nil
+	rs.mu.Unlock()
+
+	for _, rf := range files {
+		rf.close()
+	}
+}
@@ -0,0 +1,167 @@
+// Package sort implements an external merge sort for streams of CSV
+// records that may be too large to hold in memory all at once.
+package sort
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+
+	"github.com/waterfountain1996/csvsorter/progress"
+)
+
+// Record is a single parsed CSV row.
+type Record []string
+
+// Comparator reports whether left should sort before right.
+type Comparator func(left, right Record) bool
+
+// Options configures a Sort run.
+type Options struct {
+	// Spec is the ordered list of key columns records are compared by.
+	Spec SortSpec
+
+	// MemoryBudget is the approximate number of bytes of record data
+	// to buffer before spilling a sorted run to disk. A value <= 0
+	// disables spilling, keeping everything in memory.
+	MemoryBudget int64
+
+	// Progress, if non-nil, is fed write throughput as records are
+	// emitted to out.
+	Progress *progress.Progress
+
+	// ExistingRuns are paths to runs spilled by a previous, interrupted
+	// sort. They are merged in alongside any new runs instead of being
+	// re-sorted.
+	ExistingRuns []string
+}
+
+// recordSize estimates the in-memory footprint of a record in bytes.
+func recordSize(r Record) int64 {
+	var n int64
+	for _, field := range r {
+		n += int64(len(field))
+	}
+	return n
+}
+
+// CancelledError is returned by Sort when ctx is cancelled before the sort
+// finishes. Runs lists the spilled run files left on disk, deliberately
+// not cleaned up, so a later --resume can merge them instead of redoing
+// the work that produced them.
+type CancelledError struct {
+	Runs []string
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("sort cancelled with %d pending run(s)", len(e.Runs))
+}
+
+// Sort reads records from in, sorts them according to opts, and writes the
+// result to out. Records are buffered in memory until opts.MemoryBudget is
+// exceeded, at which point the buffer is sorted and spilled to a temp file
+// as a run. Once the input is exhausted, runs are merged with a k-way heap
+// merge. If the whole input fit in a single run, it never touches disk.
+//
+// If ctx is cancelled before in is drained, Sort spills whatever is
+// currently buffered and returns a *CancelledError listing every run left
+// on disk instead of cleaning them up.
+func Sort(ctx context.Context, in <-chan Record, out *csv.Writer, opts Options) error {
+	cmp, err := opts.Spec.comparator()
+	if err != nil {
+		return err
+	}
+
+	runs := newRunSet()
+	for _, path := range opts.ExistingRuns {
+		rf, err := openRun(path)
+		if err != nil {
+			runs.cleanup()
+			return err
+		}
+		runs.add(rf)
+	}
+
+	var (
+		buf  []Record
+		size int64
+	)
+
+	spillBuf := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+		sort.SliceStable(buf, func(i, j int) bool { return cmp(buf[i], buf[j]) })
+		rf, err := spill(buf)
+		if err != nil {
+			return err
+		}
+		runs.add(rf)
+		buf = nil
+		size = 0
+		return nil
+	}
+
+	cancel := func() error {
+		if err := spillBuf(); err != nil {
+			runs.cleanup()
+			return err
+		}
+		return &CancelledError{Runs: runs.paths()}
+	}
+
+drain:
+	for {
+		select {
+		case record, ok := <-in:
+			if !ok {
+				// in can be closed either because every record was read,
+				// or because ctx was cancelled (e.g. a pipeline worker
+				// failed) and its producer gave up mid-stream; select
+				// doesn't guarantee the ctx.Done() case below fires
+				// first when both are ready, so check ctx explicitly
+				// instead of trusting the close to mean "done".
+				if ctx.Err() != nil {
+					return cancel()
+				}
+				break drain
+			}
+			buf = append(buf, record)
+			size += recordSize(record)
+			if opts.MemoryBudget > 0 && size >= opts.MemoryBudget {
+				if err := spillBuf(); err != nil {
+					runs.cleanup()
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return cancel()
+		}
+	}
+
+	// Fast path: nothing was spilled, either as an existing run or
+	// mid-flight, so the whole input fit in memory.
+	if runs.empty() {
+		sort.SliceStable(buf, func(i, j int) bool { return cmp(buf[i], buf[j]) })
+		for _, record := range buf {
+			if err := out.Write(record); err != nil {
+				return err
+			}
+			if opts.Progress != nil {
+				opts.Progress.Add(progress.Stats{RecordsWritten: 1})
+			}
+		}
+		out.Flush()
+		return out.Error()
+	}
+
+	if err := spillBuf(); err != nil {
+		runs.cleanup()
+		return err
+	}
+
+	err = merge(runs, out, cmp, opts.Progress)
+	runs.cleanup()
+	return err
+}
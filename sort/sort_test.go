@@ -0,0 +1,91 @@
+package sort
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"testing"
+)
+
+func sortAll(t *testing.T, opts Options, records []Record) string {
+	t.Helper()
+
+	in := make(chan Record, len(records))
+	for _, r := range records {
+		in <- r
+	}
+	close(in)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := Sort(context.Background(), in, w, opts); err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+	return buf.String()
+}
+
+func TestSortInMemory(t *testing.T) {
+	records := []Record{{"3"}, {"1"}, {"2"}}
+	spec := SortSpec{{Index: 0, Kind: KindInt}}
+
+	got := sortAll(t, Options{Spec: spec}, records)
+	want := "1\n2\n3\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSortSpillsAndMerges(t *testing.T) {
+	records := []Record{{"5"}, {"3"}, {"1"}, {"4"}, {"2"}}
+	spec := SortSpec{{Index: 0, Kind: KindInt}}
+
+	// A memory budget no larger than a single record forces every record
+	// into its own spilled run, exercising the k-way merge path instead
+	// of the in-memory fast path.
+	got := sortAll(t, Options{Spec: spec, MemoryBudget: 1}, records)
+	want := "1\n2\n3\n4\n5\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSortCancelledReturnsCancelledError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in := make(chan Record)
+	go func() {
+		// A producer that never sends anything still lets Sort observe
+		// ctx.Done() instead of a clean channel close.
+		<-ctx.Done()
+	}()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	spec := SortSpec{{Index: 0, Kind: KindInt}}
+	err := Sort(ctx, in, w, Options{Spec: spec})
+
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Fatalf("Sort error = %v, want *CancelledError", err)
+	}
+}
+
+func TestSortExistingRunsAreMergedNotResorted(t *testing.T) {
+	rf, err := spill([]Record{{"1"}, {"3"}})
+	if err != nil {
+		t.Fatalf("spill: %v", err)
+	}
+	path := rf.f.Name()
+	rf.f.Close()
+
+	records := []Record{{"2"}, {"4"}}
+	spec := SortSpec{{Index: 0, Kind: KindInt}}
+
+	got := sortAll(t, Options{Spec: spec, ExistingRuns: []string{path}}, records)
+	want := "1\n2\n3\n4\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
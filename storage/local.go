@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register("file", func(u *url.URL) (Storage, error) { return localStorage{}, nil })
+}
+
+// localStorage implements Storage over the local filesystem. It backs bare
+// paths as well as "file://" URIs.
+type localStorage struct{}
+
+func (localStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (localStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+func (localStorage) Walk(ctx context.Context, prefix string, fn WalkFunc) error {
+	return filepath.WalkDir(prefix, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".csv") {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		return fn(path)
+	})
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func init() {
+	Register("s3", newS3Storage)
+}
+
+// s3Storage implements Storage over a single S3 bucket, named by the URL's
+// host in an "s3://bucket/key" URI.
+type s3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+func newS3Storage(u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3: URL must be of the form s3://bucket/key, got %q", u.String())
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("s3: loading AWS config: %w", err)
+	}
+	return &s3Storage{client: s3.NewFromConfig(cfg), bucket: u.Host}, nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Storage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return newS3Writer(ctx, s.client, s.bucket, name), nil
+}
+
+func (s *s3Storage) Walk(ctx context.Context, prefix string, fn WalkFunc) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if !strings.HasSuffix(key, ".csv") {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// s3Writer streams writes to S3 through an io.Pipe, so the multipart
+// uploader can read from it without the whole object ever sitting in
+// memory at once. The upload only completes once Close has been called.
+type s3Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func newS3Writer(ctx context.Context, client *s3.Client, bucket, key string) *s3Writer {
+	pr, pw := io.Pipe()
+	w := &s3Writer{pw: pw, done: make(chan error, 1)}
+
+	uploader := manager.NewUploader(client)
+	go func() {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   pr,
+		})
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *s3Writer) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// stdioStorage reads from stdin and writes to stdout, for the "-" pseudo
+// path. Walk treats "-" as its own single entry, since stdin can't be
+// listed.
+type stdioStorage struct{}
+
+func (stdioStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return io.NopCloser(os.Stdin), nil
+}
+
+func (stdioStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return nopWriteCloser{os.Stdout}, nil
+}
+
+func (stdioStorage) Walk(ctx context.Context, prefix string, fn WalkFunc) error {
+	return fn("-")
+}
+
+// nopWriteCloser adapts an io.Writer that must not be closed (stdout) to
+// io.WriteCloser.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,85 @@
+// Package storage abstracts CSV input and output away from the local
+// filesystem, similar to the backend abstraction in restic and porg: a
+// Storage knows how to open, create and list files for one URL scheme, and
+// backends register themselves by scheme so -i, -o and -d can all accept a
+// "file://", "s3://" or "webdav://" URI interchangeably.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Storage opens, creates and lists files for a single backend.
+type Storage interface {
+	// Open returns a reader for name. The caller must Close it.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Create returns a writer that (over)writes name. The caller must
+	// Close it; on some backends the write is only committed on Close.
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+
+	// Walk calls fn once for every file under prefix whose name ends in
+	// ".csv", in the backend's natural order.
+	Walk(ctx context.Context, prefix string, fn WalkFunc) error
+}
+
+// WalkFunc is called by Walk for every matching file.
+type WalkFunc func(name string) error
+
+// Factory builds the Storage for a parsed URL.
+type Factory func(u *url.URL) (Storage, error)
+
+var backends = map[string]Factory{}
+
+// Register adds a backend factory for scheme. It is meant to be called
+// from the init function of a file implementing that backend.
+func Register(scheme string, f Factory) {
+	backends[scheme] = f
+}
+
+// Parse resolves a -i/-o/-d argument to a Storage and the path or key to
+// use with it. A bare path or a "file://" URI resolves to the local
+// filesystem; "-" or a "stdio://" URI resolves to stdin/stdout; anything
+// else must use a scheme registered by a backend.
+func Parse(raw string) (Storage, string, error) {
+	if raw == "-" {
+		return stdioStorage{}, "-", nil
+	}
+
+	// A bare local path can legally contain a colon before its first
+	// slash (e.g. "report:2024.csv"), which url.Parse happily mistakes
+	// for a scheme; only treat raw as a URI once it actually has one.
+	if !strings.Contains(raw, "://") {
+		return localStorage{}, raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing %q: %w", raw, err)
+	}
+	if u.Scheme == "stdio" {
+		return stdioStorage{}, "-", nil
+	}
+	if u.Scheme == "file" {
+		return localStorage{}, u.Host + u.Path, nil
+	}
+
+	f, ok := backends[u.Scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown storage scheme %q", u.Scheme)
+	}
+	s, err := f(u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := u.Path
+	if len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+	return s, name, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+func init() {
+	Register("webdav", newWebDAVStorage)
+}
+
+// webdavStorage implements Storage over a WebDAV server, addressed as
+// "webdav://host/path".
+type webdavStorage struct {
+	client *gowebdav.Client
+}
+
+func newWebDAVStorage(u *url.URL) (Storage, error) {
+	root := url.URL{Scheme: "http", Host: u.Host}
+
+	user, pass := "", ""
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+
+	return &webdavStorage{client: gowebdav.NewClient(root.String(), user, pass)}, nil
+}
+
+func (s *webdavStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.client.ReadStream(name)
+}
+
+func (s *webdavStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return &webdavWriter{client: s.client, name: name}, nil
+}
+
+func (s *webdavStorage) Walk(ctx context.Context, prefix string, fn WalkFunc) error {
+	var walk func(path string) error
+	walk = func(path string) error {
+		entries, err := s.client.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			full := path + "/" + e.Name()
+			if e.IsDir() {
+				if err := walk(full); err != nil {
+					return err
+				}
+				continue
+			}
+			if !strings.HasSuffix(e.Name(), ".csv") {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if err := fn(full); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(prefix)
+}
+
+// webdavWriter buffers the whole write in memory, since gowebdav.Write
+// takes a []byte rather than accepting a stream.
+type webdavWriter struct {
+	client *gowebdav.Client
+	name   string
+	buf    bytes.Buffer
+}
+
+func (w *webdavWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *webdavWriter) Close() error {
+	return w.client.Write(w.name, w.buf.Bytes(), 0o644)
+}